@@ -2,51 +2,88 @@ package main
 
 import (
 	"fmt"
-	"github.com/davecgh/go-spew/spew"
+	"os"
+	"time"
+
 	"github.com/justnat3/natedns/internal/dns"
-	"net"
+)
+
+const upstream = "8.8.8.8:53"
+
+const (
+	maxCacheEntries = 4096
+	cacheSweep      = 30 * time.Second
+	forwardTimeout  = 5 * time.Second
+)
+
+// Set zoneFilePath to serve zoneOrigin authoritatively from a local
+// RFC 1035 master file, falling through to upstream for everything
+// else. Left empty, natedns is a pure forwarder as before.
+const (
+	zoneFilePath = ""
+	zoneOrigin   = "example.com."
+	zoneTTL      = 3600
 )
 
 func main() {
 	fmt.Println("Resolver Loaded...")
-	addr := net.UDPAddr{Port: 2053, IP: net.IPv4zero}
-	conn, err := net.ListenUDP("udp", &addr)
-	if err != nil {
-		panic(err)
-	}
 
-	bb := make([]byte, 128)
-	for {
-		rlen, _, err := conn.ReadFromUDP(bb)
+	cache := dns.NewCache(maxCacheEntries, cacheSweep)
+	defer cache.Close()
+
+	forwarder := &dns.ForwardHandler{Upstreams: []string{upstream}, Timeout: forwardTimeout}
+
+	forward := dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Message) {
+		if cached, ok := cache.Get(r); ok {
+			w.WriteMessage(dns.NewMessage(cached))
+			return
+		}
+		r.SetEDNS(dns.NewEDNS(dns.MaxUDPSize))
+		forwarder.ServeDNS(&cachingResponseWriter{ResponseWriter: w, query: r, cache: cache}, r)
+	})
+
+	var handler dns.Handler = forward
+	if zoneFilePath != "" {
+		zone, err := loadZone(zoneFilePath)
 		if err != nil {
 			panic(err)
 		}
-		if rlen > 2 {
-			break
-		}
+		handler = &dns.ZoneHandler{Zone: zone, Next: forward}
 	}
-	defer conn.Close()
 
-	//spew.Dump(bb)
-	message := dns.NewMessage(bb)
-	nb := message.Write()
-	raddr := &net.UDPAddr{Port: 53, IP: net.IP{8, 8, 8, 8}}
-	rn, err := conn.WriteToUDP(nb, raddr)
-	if err != nil {
-		panic(err)
-	}
-	fmt.Println(rn)
-	rbb := make([]byte, 128)
-	for {
-		rrlen, _, err := conn.ReadFromUDP(rbb)
-		if err != nil {
+	mux := dns.NewServeMux()
+	mux.HandleDefault(handler)
+
+	server := &dns.Server{Handler: mux}
+	go func() {
+		if err := server.ListenAndServe(":2053", "tcp"); err != nil {
 			panic(err)
 		}
-		if rrlen > 2 {
-			fmt.Println("recv:", rrlen)
-			spew.Dump(rbb)
-			break
-		}
+	}()
+	if err := server.ListenAndServe(":2053", "udp"); err != nil {
+		panic(err)
 	}
+}
+
+func loadZone(path string) (*dns.Zone, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return dns.ParseZone(f, zoneOrigin, zoneTTL)
+}
+
+// cachingResponseWriter stores whatever the wrapped ResponseWriter is
+// asked to send back to a client into the cache, keyed by the query
+// that produced it.
+type cachingResponseWriter struct {
+	dns.ResponseWriter
+	query *dns.Message
+	cache *dns.Cache
+}
 
+func (w *cachingResponseWriter) WriteMessage(m *dns.Message) error {
+	w.cache.Put(w.query, m)
+	return w.ResponseWriter.WriteMessage(m)
 }
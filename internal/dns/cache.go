@@ -0,0 +1,252 @@
+package dns
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+	"time"
+)
+
+// cacheKey identifies a cached answer by its question tuple; qname is
+// lowercased since domain names are case-insensitive (RFC 4343).
+type cacheKey struct {
+	qname  string
+	qtype  uint16
+	qclass uint16
+}
+
+func newCacheKey(m *Message) cacheKey {
+	return cacheKey{qname: strings.ToLower(m.q.qname), qtype: m.q.qtype, qclass: m.q.qclass}
+}
+
+// cacheEntry is a cached wire-format response together with the TTL it
+// was stored with and when it was stored, so remaining TTL can be
+// derived on lookup instead of kept in sync separately.
+type cacheEntry struct {
+	key      cacheKey
+	response []byte
+	ttl      uint32
+	storedAt time.Time
+	negative bool
+}
+
+func (e *cacheEntry) expired(now time.Time) bool {
+	return now.Sub(e.storedAt) >= time.Duration(e.ttl)*time.Second
+}
+
+func (e *cacheEntry) remainingTTL(now time.Time) uint32 {
+	elapsed := uint32(now.Sub(e.storedAt) / time.Second)
+	if elapsed >= e.ttl {
+		return 0
+	}
+	return e.ttl - elapsed
+}
+
+// CacheStats is a snapshot of a Cache's running counters.
+type CacheStats struct {
+	Hits         uint64
+	Misses       uint64
+	Evictions    uint64
+	NegativeHits uint64
+}
+
+// Cache is a bounded, TTL-aware positive/negative response cache sitting
+// between the listener and the upstream forwarder. Entries are keyed by
+// (qname, qtype, qclass); the least-recently-used entry is evicted once
+// maxEntries is exceeded, and a background sweeper periodically purges
+// entries whose TTL has run out.
+type Cache struct {
+	mu         sync.Mutex
+	maxEntries int
+	ll         *list.List // most-recently-used at the front
+	items      map[cacheKey]*list.Element
+	stats      CacheStats
+	stop       chan struct{}
+}
+
+// NewCache returns a Cache holding at most maxEntries responses. If
+// sweepInterval is non-zero, a background goroutine purges expired
+// entries on that interval until Close is called.
+func NewCache(maxEntries int, sweepInterval time.Duration) *Cache {
+	c := &Cache{
+		maxEntries: maxEntries,
+		ll:         list.New(),
+		items:      make(map[cacheKey]*list.Element),
+		stop:       make(chan struct{}),
+	}
+	if sweepInterval > 0 {
+		go c.sweep(sweepInterval)
+	}
+	return c
+}
+
+func (c *Cache) sweep(interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			c.evictExpired()
+		case <-c.stop:
+			return
+		}
+	}
+}
+
+// Close stops the background sweeper, if any. It does not clear the
+// cache.
+func (c *Cache) Close() {
+	close(c.stop)
+}
+
+func (c *Cache) evictExpired() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	for e := c.ll.Back(); e != nil; {
+		prev := e.Prev()
+		entry := e.Value.(*cacheEntry)
+		if entry.expired(now) {
+			c.ll.Remove(e)
+			delete(c.items, entry.key)
+			c.stats.Evictions++
+		}
+		e = prev
+	}
+}
+
+// minTTL returns the TTL resp should be cached under, and
+// whether it represents a negative (NXDOMAIN/NODATA) answer. Positive
+// answers use the lowest TTL across the answer RRs; negative answers
+// use the SOA MINIMUM from the authority section, bounded by the SOA's
+// own TTL, per RFC 2308. Responses carrying neither are not cacheable.
+func minTTL(resp *Message) (ttl uint32, negative bool) {
+	if len(resp.answers) > 0 {
+		ttl = resp.answers[0].ttl
+		for _, rr := range resp.answers[1:] {
+			if rr.ttl < ttl {
+				ttl = rr.ttl
+			}
+		}
+		return ttl, false
+	}
+	for _, rr := range resp.authorities {
+		if soa, ok := rr.rdata.(*SOARecord); ok {
+			ttl = soa.Minimum
+			if rr.ttl < ttl {
+				ttl = rr.ttl
+			}
+			return ttl, true
+		}
+	}
+	return 0, false
+}
+
+// ageResponse re-encodes entry's response with each RR's TTL lowered by
+// the seconds elapsed since it was stored (stopping at zero) and its
+// transaction ID rewritten to id, since a cache hit is answering a
+// different query than the one that originally produced this response.
+// A negative entry's RRs instead all carry the entry's remaining
+// negative-caching TTL, per RFC 2308.
+func ageResponse(entry *cacheEntry, now time.Time, id uint16) []byte {
+	m := NewMessage(entry.response)
+	elapsed := uint32(now.Sub(entry.storedAt) / time.Second)
+	for _, rr := range append(m.answers, m.authorities...) {
+		switch {
+		case entry.negative:
+			rr.ttl = entry.remainingTTL(now)
+		case rr.ttl > elapsed:
+			rr.ttl -= elapsed
+		default:
+			rr.ttl = 0
+		}
+	}
+	m.hdr.id = id
+	return m.Write()
+}
+
+// Put stores resp as the cached answer to query, keyed by query's
+// question, using the minimum TTL across resp's RRs (or the
+// negative-caching TTL derived from its SOA). Responses with nothing to
+// bound a TTL by are not stored.
+func (c *Cache) Put(query, resp *Message) {
+	ttl, negative := minTTL(resp)
+	if ttl == 0 {
+		return
+	}
+
+	key := newCacheKey(query)
+	entry := &cacheEntry{
+		key:      key,
+		response: resp.Write(),
+		ttl:      ttl,
+		storedAt: time.Now(),
+		negative: negative,
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		c.ll.Remove(el)
+	}
+	c.items[key] = c.ll.PushFront(entry)
+	for c.maxEntries > 0 && c.ll.Len() > c.maxEntries {
+		back := c.ll.Back()
+		if back == nil {
+			break
+		}
+		c.ll.Remove(back)
+		delete(c.items, back.Value.(*cacheEntry).key)
+		c.stats.Evictions++
+	}
+}
+
+// Get returns the cached wire-format response to query, if any, with
+// its RR TTLs decremented by the time elapsed since it was stored. ok
+// is false on a miss or if the entry has expired.
+func (c *Cache) Get(query *Message) (response []byte, ok bool) {
+	key := newCacheKey(query)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, found := c.items[key]
+	if !found {
+		c.stats.Misses++
+		return nil, false
+	}
+
+	entry := el.Value.(*cacheEntry)
+	now := time.Now()
+	if entry.expired(now) {
+		c.ll.Remove(el)
+		delete(c.items, key)
+		c.stats.Evictions++
+		c.stats.Misses++
+		return nil, false
+	}
+
+	c.ll.MoveToFront(el)
+	c.stats.Hits++
+	if entry.negative {
+		c.stats.NegativeHits++
+	}
+	return ageResponse(entry, now, query.hdr.id), true
+}
+
+// Purge drops the cached entry for query, if any.
+func (c *Cache) Purge(query *Message) {
+	key := newCacheKey(query)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		c.ll.Remove(el)
+		delete(c.items, key)
+	}
+}
+
+// Stats returns a snapshot of the cache's hit/miss/eviction counters.
+func (c *Cache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
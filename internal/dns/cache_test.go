@@ -0,0 +1,104 @@
+package dns
+
+import "testing"
+
+func mustQuery(id uint16, qname string, qt int) *Message {
+	return &Message{
+		hdr: header{id: id, questions: 1, rd: 1},
+		q:   question{qname: qname, qtype: uint16(qt), qclass: uint16(In)},
+	}
+}
+
+func mustAnswer(query *Message, ttl uint32) *Message {
+	resp := &Message{hdr: query.hdr, q: query.q}
+	resp.hdr.qr = 1
+	resp.answers = []*resourceRecord{
+		{name: query.q.qname, rtype: query.q.qtype, class: uint16(In), ttl: ttl, rdata: &ARecord{IP: mustParseIP("192.0.2.1").To4()}},
+	}
+	return resp
+}
+
+// TestCacheGetRewritesTransactionID checks that a cache hit answers
+// with the querying client's own id, not whatever id the response that
+// first populated the entry happened to carry.
+func TestCacheGetRewritesTransactionID(t *testing.T) {
+	c := NewCache(10, 0)
+	defer c.Close()
+
+	first := mustQuery(0xAAAA, "example.com", A)
+	c.Put(first, mustAnswer(first, 300))
+
+	second := mustQuery(0xBBBB, "example.com", A)
+	raw, ok := c.Get(second)
+	if !ok {
+		t.Fatal("expected a cache hit")
+	}
+
+	got := NewMessage(raw)
+	if got.hdr.id != 0xBBBB {
+		t.Errorf("cached reply id = %#x, want %#x", got.hdr.id, 0xBBBB)
+	}
+}
+
+// TestCacheLRUEviction checks that once maxEntries is exceeded, the
+// least-recently-used entry (not touched by Get) is the one dropped.
+func TestCacheLRUEviction(t *testing.T) {
+	c := NewCache(2, 0)
+	defer c.Close()
+
+	a := mustQuery(1, "a.example.com", A)
+	b := mustQuery(2, "b.example.com", A)
+	cq := mustQuery(3, "c.example.com", A)
+
+	c.Put(a, mustAnswer(a, 300))
+	c.Put(b, mustAnswer(b, 300))
+	// Touch a so b becomes the least recently used.
+	if _, ok := c.Get(a); !ok {
+		t.Fatal("expected a hit for a.example.com")
+	}
+	c.Put(cq, mustAnswer(cq, 300))
+
+	if _, ok := c.Get(b); ok {
+		t.Error("b.example.com should have been evicted, but was still cached")
+	}
+	if _, ok := c.Get(a); !ok {
+		t.Error("a.example.com should still be cached")
+	}
+	if _, ok := c.Get(cq); !ok {
+		t.Error("c.example.com should still be cached")
+	}
+	if c.Stats().Evictions == 0 {
+		t.Error("expected at least one eviction to be recorded")
+	}
+}
+
+// TestCacheNegativeCaching checks that an NXDOMAIN response (no
+// answers, an authority SOA) is cached under the SOA MINIMUM, bounded
+// by the SOA's own TTL, per RFC 2308.
+func TestCacheNegativeCaching(t *testing.T) {
+	c := NewCache(10, 0)
+	defer c.Close()
+
+	query := mustQuery(1, "nope.example.com", A)
+	resp := &Message{hdr: query.hdr, q: query.q}
+	resp.hdr.qr = 1
+	resp.hdr.rcode = uint8(ErrorName)
+	resp.authorities = []*resourceRecord{
+		{
+			name: "example.com", rtype: uint16(Soa), class: uint16(In), ttl: 3600,
+			rdata: &SOARecord{MName: "ns1.example.com", RName: "hostmaster.example.com", Minimum: 60},
+		},
+	}
+	c.Put(query, resp)
+
+	raw, ok := c.Get(mustQuery(2, "nope.example.com", A))
+	if !ok {
+		t.Fatal("expected negative response to be cached")
+	}
+	if got := NewMessage(raw).authorities[0].ttl; got != 60 {
+		t.Errorf("cached SOA TTL = %d, want 60 (SOA MINIMUM)", got)
+	}
+	if c.Stats().NegativeHits != 1 {
+		t.Errorf("NegativeHits = %d, want 1", c.Stats().NegativeHits)
+	}
+}
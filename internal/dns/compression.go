@@ -0,0 +1,126 @@
+package dns
+
+import (
+	"errors"
+	"strings"
+)
+
+// name compression, RFC 1035 4.1.4
+const (
+	pointerMask      = 0xC0   // top two bits of a length octet mark a pointer
+	maxPointerOffset = 0x3FFF // pointers are 14 bits
+	maxPointerHops   = 20     // generous bound on pointer chains, loops aside
+	maxNameLength    = 255    // total octets, RFC 1035 3.1
+)
+
+var (
+	ErrorNameTooLong     = errors.New("qname: name exceeds 255 octets")
+	ErrorTooManyPointers = errors.New("qname: too many compression pointers")
+	ErrorBadPointer      = errors.New("qname: compression pointer does not point backwards")
+)
+
+// compressionMap remembers the wire offset at which a domain name suffix
+// was first written, so later names can point back at it instead of
+// repeating the labels.
+type compressionMap map[string]uint16
+
+// writeQName encodes qname starting at offset (its position in the
+// message being built) and records any new suffixes in comp so
+// subsequent names can compress against it. offset/comp may be left
+// zero-valued/nil to always write the name in full.
+func writeQName(qname string, offset int, comp compressionMap) []byte {
+	if qname == "" {
+		return []byte{0}
+	}
+
+	labels := strings.Split(qname, ".")
+	var buf []byte
+	pos := offset
+	for i, label := range labels {
+		if len(label) > 0x3f {
+			return nil
+		}
+
+		suffix := strings.Join(labels[i:], ".")
+		if comp != nil {
+			if ptr, ok := comp[suffix]; ok {
+				buf = append(buf, byte(pointerMask|(ptr>>8)), byte(ptr&0xff))
+				return buf
+			}
+			if pos <= maxPointerOffset {
+				comp[suffix] = uint16(pos)
+			}
+		}
+
+		buf = append(buf, byte(len(label)))
+		buf = append(buf, label...)
+		pos += 1 + len(label)
+	}
+	buf = append(buf, 0)
+	return buf
+}
+
+// readQName decodes the domain name starting at pos in msg, following
+// compression pointers as needed, and returns the name along with the
+// position immediately after it in the caller's stream (i.e. after the
+// terminating root label or the two-octet pointer, never following a
+// jump).
+func readQName(msg []byte, pos int) (string, int) {
+	var labels []string
+	hops := 0
+	next := -1
+	cur := pos
+
+	for {
+		if cur < 0 || cur >= len(msg) {
+			panic(ErrorInvalidQNameLength)
+		}
+
+		lb := msg[cur]
+		if lb&pointerMask == pointerMask {
+			if cur+1 >= len(msg) {
+				panic(ErrorInvalidQNameLength)
+			}
+			if next == -1 {
+				next = cur + 2
+			}
+			ptr := (int(lb&^pointerMask) << 8) | int(msg[cur+1])
+			if ptr >= cur {
+				panic(ErrorBadPointer)
+			}
+			hops++
+			if hops > maxPointerHops {
+				panic(ErrorTooManyPointers)
+			}
+			cur = ptr
+			continue
+		}
+
+		if lb == 0 {
+			cur++
+			break
+		}
+
+		if lb > 0x3f {
+			panic(ErrorInvalidQNameLength)
+		}
+
+		start := cur + 1
+		end := start + int(lb)
+		if end > len(msg) {
+			panic(ErrorInvalidQNameLength)
+		}
+		labels = append(labels, string(msg[start:end]))
+		cur = end
+	}
+
+	if next == -1 {
+		next = cur
+	}
+
+	name := strings.Join(labels, ".")
+	if len(name) > maxNameLength {
+		panic(ErrorNameTooLong)
+	}
+	return name, next
+}
@@ -0,0 +1,75 @@
+package dns
+
+import "testing"
+
+func TestQNameRoundTrip(t *testing.T) {
+	cases := []string{
+		"",
+		"example.com",
+		"www.example.com",
+		"a.b.c.d.example.com",
+	}
+	for _, name := range cases {
+		buf := writeQName(name, 0, nil)
+		got, next := readQName(buf, 0)
+		if got != name {
+			t.Errorf("writeQName/readQName(%q): got %q", name, got)
+		}
+		if next != len(buf) {
+			t.Errorf("writeQName/readQName(%q): next = %d, want %d", name, next, len(buf))
+		}
+	}
+}
+
+// TestQNameCompression writes two names sharing a suffix into the same
+// message and checks the second compresses down to a pointer, and both
+// still decode correctly.
+func TestQNameCompression(t *testing.T) {
+	comp := make(compressionMap)
+	first := writeQName("www.example.com", 0, comp)
+
+	secondOffset := len(first)
+	second := writeQName("mail.example.com", secondOffset, comp)
+	if len(second) >= len("mail.example.com")+2 {
+		t.Fatalf("second name did not compress: %d bytes", len(second))
+	}
+
+	msg := append(append([]byte{}, first...), second...)
+
+	got1, next1 := readQName(msg, 0)
+	if got1 != "www.example.com" || next1 != len(first) {
+		t.Errorf("first name: got %q, next %d", got1, next1)
+	}
+
+	got2, next2 := readQName(msg, secondOffset)
+	if got2 != "mail.example.com" {
+		t.Errorf("second name: got %q", got2)
+	}
+	if next2 != len(msg) {
+		t.Errorf("second name: next = %d, want %d", next2, len(msg))
+	}
+}
+
+func TestReadQNamePointerLoopPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("readQName did not panic on a pointer loop")
+		}
+	}()
+
+	// A single compression pointer at offset 0 that points at itself.
+	// readQName rejects it because a pointer must point strictly
+	// backwards, so the loop is never followed.
+	msg := []byte{0xC0, 0x00}
+	readQName(msg, 0)
+}
+
+func TestReadQNameTruncatedPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("readQName did not panic on a truncated label")
+		}
+	}()
+	msg := []byte{5, 'h', 'e', 'l'} // label claims 5 octets, only 3 present
+	readQName(msg, 0)
+}
@@ -0,0 +1,97 @@
+package dns
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+// Buffer sizes per RFC 1035 4.2.1/4.2.2. TCP messages are framed by a
+// length prefix so there's no hard ceiling beyond the prefix itself;
+// MaxTCPSize is just a sane allocation bound. MaxUDPSize leaves room for
+// EDNS0 payloads larger than the classic 512-octet limit.
+const (
+	MaxUDPSize = 4096
+	MaxTCPSize = 65535
+)
+
+// Conn is a DNS-framed connection to a single remote server. For TCP,
+// ReadMessage/WriteMessage transparently add/strip the two-octet
+// big-endian length prefix required by RFC 1035 4.2.2; for UDP each
+// message is simply a datagram.
+type Conn struct {
+	network string // "udp" or "tcp"
+	nc      net.Conn
+}
+
+// Dial opens a Conn to addr over network ("udp" or "tcp").
+func Dial(network, addr string) (*Conn, error) {
+	return DialTimeout(network, addr, 0)
+}
+
+// DialTimeout is Dial with a connect timeout; zero means no timeout.
+func DialTimeout(network, addr string, timeout time.Duration) (*Conn, error) {
+	nc, err := net.DialTimeout(network, addr, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return &Conn{network: network, nc: nc}, nil
+}
+
+// ListenUDP opens a UDP socket for receiving queries on addr.
+func ListenUDP(addr string) (*net.UDPConn, error) {
+	a, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return net.ListenUDP("udp", a)
+}
+
+// WriteMessage writes a complete DNS message, framing it for TCP.
+func (c *Conn) WriteMessage(b []byte) error {
+	if c.network == "tcp" {
+		if len(b) > 0xffff {
+			return fmt.Errorf("dns: message too long for tcp framing: %d", len(b))
+		}
+		prefix := []byte{uint8(len(b) >> 8), uint8(len(b) & 0xff)}
+		_, err := c.nc.Write(append(prefix, b...))
+		return err
+	}
+	_, err := c.nc.Write(b)
+	return err
+}
+
+// ReadMessage reads one complete DNS message, stripping the TCP length
+// prefix where applicable.
+func (c *Conn) ReadMessage() ([]byte, error) {
+	if c.network == "tcp" {
+		var lenBuf [2]byte
+		if _, err := io.ReadFull(c.nc, lenBuf[:]); err != nil {
+			return nil, err
+		}
+		n := binary.BigEndian.Uint16(lenBuf[:])
+		buf := make([]byte, n)
+		if _, err := io.ReadFull(c.nc, buf); err != nil {
+			return nil, err
+		}
+		return buf, nil
+	}
+
+	buf := make([]byte, MaxUDPSize)
+	n, err := c.nc.Read(buf)
+	if err != nil {
+		return nil, err
+	}
+	return buf[:n], nil
+}
+
+// SetDeadline propagates to the underlying connection.
+func (c *Conn) SetDeadline(t time.Time) error {
+	return c.nc.SetDeadline(t)
+}
+
+func (c *Conn) Close() error {
+	return c.nc.Close()
+}
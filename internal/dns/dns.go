@@ -4,9 +4,6 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
-	"strings"
-
-	"github.com/davecgh/go-spew/spew"
 )
 
 type qtype = int
@@ -158,32 +155,35 @@ func (hdr header) String(withNewLines bool) string {
 	)
 }
 
-func read16(b []byte) (uint16, []byte) {
-	return binary.BigEndian.Uint16(b[0:2]), b[2:]
+func read16(b []byte, pos int) (uint16, int) {
+	return binary.BigEndian.Uint16(b[pos : pos+2]), pos + 2
 }
 
-func read32(b []byte) (uint32, []byte) {
-	return binary.BigEndian.Uint32(b[0:4]), b[4:]
+func read32(b []byte, pos int) (uint32, int) {
+	return binary.BigEndian.Uint32(b[pos : pos+4]), pos + 4
 }
 
-func NewHeader(b []byte) (*header, []byte) {
-	spew.Dump(b)
+// NewHeader decodes the fixed 12-octet header starting at b[0] and
+// returns the position immediately following it.
+func NewHeader(b []byte) (*header, int) {
 	hdr := &header{}
-	hdr.id, b = read16(b)
-	qinfo, b := read16(b)
+	pos := 0
+	hdr.id, pos = read16(b, pos)
+	var qinfo uint16
+	qinfo, pos = read16(b, pos)
 	hdr.qr = uint8((qinfo & QrMask) >> 15)
 	hdr.aa = uint8((qinfo & AAMask) >> 10)
 	hdr.tc = uint8((qinfo & TCMask) >> 9)
 	hdr.rd = uint8((qinfo & RDMask) >> 8)
 	hdr.ra = uint8((qinfo & RAMask) >> 7)
 	hdr.z = uint8((qinfo & ZMask) >> 6)
-	hdr.rcode = uint8((qinfo & RCodeMask))
+	hdr.rcode = uint8(qinfo & RCodeMask)
 	hdr.opcode = uint8((qinfo & OPCodeMask) >> 11)
-	hdr.questions, b = read16(b)
-	hdr.answers, b = read16(b)
-	hdr.authorities, b = read16(b)
-	hdr.additionals, b = read16(b)
-	return hdr, b
+	hdr.questions, pos = read16(b, pos)
+	hdr.answers, pos = read16(b, pos)
+	hdr.authorities, pos = read16(b, pos)
+	hdr.additionals, pos = read16(b, pos)
+	return hdr, pos
 }
 
 func (hdr header) Write() []byte {
@@ -211,29 +211,25 @@ type question struct {
 	qclass uint16
 }
 
-func newQuestion(b []byte) (*question, []byte) {
+// newQuestion decodes a question starting at pos and returns the
+// position immediately following it.
+func newQuestion(b []byte, pos int) (*question, int) {
 	q := &question{}
-	q.qname, b = readQName(b)
-	fmt.Println("len:", b[0])
-	fmt.Println("name:", q.qname)
-	q.qtype, b = read16(b[12:])
-	q.qclass, b = read16(b)
-	return q, b
+	q.qname, pos = readQName(b, pos)
+	q.qtype, pos = read16(b, pos)
+	q.qclass, pos = read16(b, pos)
+	return q, pos
 }
 
-func (q question) Write() []byte {
-	buff := []byte{}
-	qname := writeQName(q.qname)
-	buff = append(buff, qname...)
-	s := []byte{
-		uint8(q.qtype >> 8),
-		uint8(q.qtype & 0xff),
-		uint8(q.qclass >> 8),
-		uint8(q.qclass & 0xff),
-	}
-	buff = append(buff, s...)
-
-	return nil
+// Write encodes the question at offset (its position in the message
+// being built), compressing qname against comp.
+func (q question) Write(offset int, comp compressionMap) []byte {
+	buf := writeQName(q.qname, offset, comp)
+	buf = append(buf,
+		uint8(q.qtype>>8), uint8(q.qtype&0xff),
+		uint8(q.qclass>>8), uint8(q.qclass&0xff),
+	)
+	return buf
 }
 
 func (q question) String() string {
@@ -245,97 +241,98 @@ func (q question) String() string {
 	)
 }
 
+// Message holds a single question and, for responses, every answer and
+// authority RR natedns decoded (zero or more of each), plus EDNS(0) if
+// present among the additionals.
 type Message struct {
-	hdr header
-	q   question
-	rr  resourceRecord
+	hdr         header
+	q           question
+	answers     []*resourceRecord
+	authorities []*resourceRecord
+	edns        *EDNS
 }
 
 func (m Message) String() string {
-	return m.hdr.String(true) + "\n" + m.q.String() + "\n" + m.rr.String()
+	s := m.hdr.String(true) + "\n" + m.q.String()
+	for _, rr := range m.answers {
+		s += "\n" + rr.String()
+	}
+	for _, rr := range m.authorities {
+		s += "\n" + rr.String()
+	}
+	return s
+}
+
+// Truncated reports whether the header's TC bit is set, meaning a UDP
+// response was cut short and the query should be retried over TCP.
+func (m Message) Truncated() bool {
+	return m.hdr.tc == 1
 }
 
 var (
 	ErrorInvalidQNameLength = errors.New("qname: buffer is empty")
 )
 
-func writeQName(qname string) []byte {
-	s := strings.Split(qname, ".")
-	var b []byte
-	for _, label := range s {
-		l := len(label)
-		if l > 0x3f {
-			return nil
+// NewMessage decodes a DNS message from b. Exactly as many RRs as the
+// header's count fields claim are decoded per section, so that bare
+// queries (which carry no RRs at all) round-trip correctly.
+func NewMessage(b []byte) *Message {
+	hdr, pos := NewHeader(b)
+	q, pos := newQuestion(b, pos)
+	m := &Message{hdr: *hdr, q: *q}
+
+	for i := uint16(0); i < hdr.answers; i++ {
+		rr, next := newResourceRecord(b, pos)
+		m.answers = append(m.answers, rr)
+		pos = next
+	}
+	for i := uint16(0); i < hdr.authorities; i++ {
+		rr, next := newResourceRecord(b, pos)
+		m.authorities = append(m.authorities, rr)
+		pos = next
+	}
+	for i := uint16(0); i < hdr.additionals; i++ {
+		rr, next := newResourceRecord(b, pos)
+		pos = next
+		if qtype(rr.rtype) != Opt {
+			continue
 		}
-		b = append(b, uint8(l))
-		for _, by := range label {
-			b = append(b, uint8(by))
+		var raw []byte
+		if rawData, ok := rr.rdata.(*RawRData); ok {
+			raw = rawData.Bytes
+		}
+		if edns, err := ednsFromRR(rr, raw); err == nil {
+			m.edns = edns
 		}
 	}
-	b = append(b, byte(0))
-	return b
+	return m
 }
 
-// right now I do not support more than 1 RFC 1035 label
-func readQName(buff []byte) (string, []byte) {
-	// this is the initial length
-
-	labelLen := uint8(buff[0])
-	buff = buff[1:]
-
-	// 06 67 6f 6f 67 6c 65 03  63 6f 6d 00  |.google.com.|
-	// in this case the first byte is "6" which is "google"
-	// then after we've read 6, we get the byte "3" which is "com" and then NULL
-	// which means that we are done reading.
-	if labelLen > 63 {
-		panic(ErrorInvalidQNameLength)
+// Write encodes the message, compressing names across the question,
+// answers and authorities, and sets the header's count fields to match
+// what is actually written rather than whatever was last decoded.
+func (m Message) Write() []byte {
+	hdr := m.hdr
+	hdr.questions = 1
+	hdr.answers = uint16(len(m.answers))
+	hdr.authorities = uint16(len(m.authorities))
+	hdr.additionals = 0
+	if m.edns != nil {
+		hdr.additionals = 1
 	}
 
-	if len(buff) < 1 {
-		panic(ErrorInvalidQNameLength)
+	comp := compressionMap{}
+	bb := append([]byte{}, hdr.Write()...)
+	bb = append(bb, m.q.Write(len(bb), comp)...)
+	for _, rr := range m.answers {
+		bb = append(bb, rr.Write(len(bb), comp)...)
 	}
-
-	str := ""
-	pos := 0
-	for {
-		if buff[pos] == 0 {
-			break
-		}
-
-		if int(labelLen) == 0 {
-			str += string('.')
-			labelLen = uint8(buff[pos])
-			pos += 1
-		}
-
-		str += string(buff[pos])
-		labelLen -= 1
-		pos += 1
+	for _, rr := range m.authorities {
+		bb = append(bb, rr.Write(len(bb), comp)...)
 	}
-
-	if len(str) < 1 {
-		panic(ErrorInvalidQNameLength)
+	if m.edns != nil {
+		bb = append(bb, m.edns.rr().Write(len(bb), comp)...)
 	}
-	return str, buff[pos:]
-}
-
-func NewMessage(b []byte) *Message {
-	hdr, b := NewHeader(b)
-	hdr.Write()
-	q, b := newQuestion(b)
-	rr, b := newResourceRecord(b)
-	rr.name = q.qname
-	fmt.Println("resulting length:", len(b), rr.name)
-	m := &Message{hdr: *hdr, q: *q, rr: *rr}
-	return m
-}
-
-func (m Message) Write() []byte {
-	bb := []byte{}
-	bb = append(bb, m.hdr.Write()...)
-	bb = append(bb, m.q.Write()...)
-	bb = append(bb, m.rr.Write()...)
-	spew.Dump(bb)
 	return bb
 }
 
@@ -346,51 +343,61 @@ type resourceRecord struct {
 	class  uint16
 	ttl    uint32
 	length uint16
-	rdata  uint32
+	rdata  RData
 }
 
-func (rr resourceRecord) Write() []byte {
-	bb := []byte{}
-	qname := writeQName(rr.name)
-	bb = append(bb, qname...)
-	r := []byte{
-		uint8(rr.rtype >> 8),
-		uint8(rr.rtype & 0xff),
-		uint8(rr.class >> 8),
-		uint8(rr.class & 0xff),
-		uint8((rr.ttl >> 24) & 0xff),
-		uint8((rr.ttl >> 16) & 0xff),
-		uint8((rr.ttl >> 8) & 0xff),
-		uint8((rr.ttl >> 0) & 0xff),
-		uint8(rr.length >> 8),
-		uint8(rr.length & 0xff),
-		uint8((rr.rdata >> 24) & 0xff),
-		uint8((rr.rdata >> 16) & 0xff),
-		uint8((rr.rdata >> 8) & 0xff),
-		uint8((rr.rdata >> 0) & 0xff),
+// Write encodes the RR at offset (its position in the message being
+// built), compressing name and any in-RDATA names against comp. length
+// is recomputed from the packed RDATA rather than trusted from decode.
+func (rr resourceRecord) Write(offset int, comp compressionMap) []byte {
+	buf := writeQName(rr.name, offset, comp)
+	buf = append(buf,
+		uint8(rr.rtype>>8), uint8(rr.rtype&0xff),
+		uint8(rr.class>>8), uint8(rr.class&0xff),
+		uint8((rr.ttl>>24)&0xff), uint8((rr.ttl>>16)&0xff), uint8((rr.ttl>>8)&0xff), uint8(rr.ttl&0xff),
+	)
+
+	var packed []byte
+	if rr.rdata != nil {
+		packed = rr.rdata.Pack(offset+len(buf)+2, comp)
 	}
-	r = append(r, bb...)
-	return r
+	buf = append(buf, uint8(len(packed)>>8), uint8(len(packed)&0xff))
+	buf = append(buf, packed...)
+	return buf
 }
 
 func (rr resourceRecord) String() string {
+	rdata := "<none>"
+	if rr.rdata != nil {
+		rdata = rr.rdata.String()
+	}
 	return fmt.Sprintf(
-		"name: %s\ntype: %d\n class: %d\n ttl: %d\n length: %d\n rdata: %d",
+		"name: %s\ntype: %d\n class: %d\n ttl: %d\n length: %d\n rdata: %s",
 		string(rr.name),
 		rr.rtype,
 		rr.class,
 		rr.ttl,
 		rr.length,
-		rr.rdata,
+		rdata,
 	)
 }
 
-func newResourceRecord(b []byte) (*resourceRecord, []byte) {
+// newResourceRecord decodes an RR starting at pos and returns the
+// position immediately following it. The RDATA is dispatched on rtype
+// via newRData.
+func newResourceRecord(b []byte, pos int) (*resourceRecord, int) {
 	rr := &resourceRecord{}
-	rr.rtype, b = read16(b)
-	rr.class, b = read16(b)
-	rr.ttl, b = read32(b)
-	rr.length, b = read16(b)
-	rr.rdata, b = read32(b)
-	return rr, b
+	rr.name, pos = readQName(b, pos)
+	rr.rtype, pos = read16(b, pos)
+	rr.class, pos = read16(b, pos)
+	rr.ttl, pos = read32(b, pos)
+	rr.length, pos = read16(b, pos)
+
+	rdata := newRData(rr.rtype)
+	if err := rdata.Unpack(b, pos, int(rr.length)); err != nil {
+		panic(err)
+	}
+	rr.rdata = rdata
+	pos += int(rr.length)
+	return rr, pos
 }
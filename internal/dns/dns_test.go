@@ -0,0 +1,56 @@
+package dns
+
+import "testing"
+
+// TestMessageMultipleAnswersAndEDNS reproduces the shape a real
+// resolver like 8.8.8.8 commonly returns: more than one answer RR plus
+// an EDNS OPT additional. Both must survive a Write/NewMessage round
+// trip intact.
+func TestMessageMultipleAnswersAndEDNS(t *testing.T) {
+	m := &Message{
+		hdr: header{id: 0x1234, qr: 1, questions: 1},
+		q:   question{qname: "example.com", qtype: uint16(A), qclass: uint16(In)},
+		answers: []*resourceRecord{
+			{name: "example.com", rtype: uint16(A), class: uint16(In), ttl: 300, rdata: &ARecord{IP: mustParseIP("192.0.2.1").To4()}},
+			{name: "example.com", rtype: uint16(A), class: uint16(In), ttl: 300, rdata: &ARecord{IP: mustParseIP("192.0.2.2").To4()}},
+		},
+		edns: NewEDNS(4096),
+	}
+
+	out := NewMessage(m.Write())
+
+	if len(out.answers) != 2 {
+		t.Fatalf("got %d answers, want 2", len(out.answers))
+	}
+	if out.answers[0].rdata.String() != "192.0.2.1" || out.answers[1].rdata.String() != "192.0.2.2" {
+		t.Errorf("answers came back as %s, %s", out.answers[0].rdata, out.answers[1].rdata)
+	}
+	if out.edns == nil {
+		t.Fatal("EDNS additional did not survive the round trip")
+	}
+	if out.edns.UDPSize != 4096 {
+		t.Errorf("edns.UDPSize = %d, want 4096", out.edns.UDPSize)
+	}
+}
+
+// TestMessageMultipleAuthorities checks the authority section round
+// trips multiple RRs the same way the answer section does.
+func TestMessageMultipleAuthorities(t *testing.T) {
+	m := &Message{
+		hdr: header{id: 1, qr: 1, questions: 1},
+		q:   question{qname: "example.com", qtype: uint16(Ns), qclass: uint16(In)},
+		authorities: []*resourceRecord{
+			{name: "example.com", rtype: uint16(Ns), class: uint16(In), ttl: 300, rdata: &NSRecord{Name: "ns1.example.com"}},
+			{name: "example.com", rtype: uint16(Ns), class: uint16(In), ttl: 300, rdata: &NSRecord{Name: "ns2.example.com"}},
+		},
+	}
+
+	out := NewMessage(m.Write())
+
+	if len(out.authorities) != 2 {
+		t.Fatalf("got %d authorities, want 2", len(out.authorities))
+	}
+	if out.authorities[0].rdata.String() != "ns1.example.com" || out.authorities[1].rdata.String() != "ns2.example.com" {
+		t.Errorf("authorities came back as %s, %s", out.authorities[0].rdata, out.authorities[1].rdata)
+	}
+}
@@ -0,0 +1,142 @@
+package dns
+
+import "encoding/binary"
+
+// Opt is the EDNS(0) pseudo-RR type, RFC 6891 6.1.2.
+const Opt qtype = 41
+
+// ednsDOMask is the DNSSEC OK bit within the OPT RR's extended flags.
+const ednsDOMask uint16 = 0x8000
+
+// EDNSOption is a single {code, data} option carried in an OPT RR's
+// RDATA, RFC 6891 6.1.2.
+type EDNSOption struct {
+	Code uint16
+	Data []byte
+}
+
+// EDNS holds the EDNS(0) pseudo-header extracted from (or to be
+// attached as) an OPT additional record.
+type EDNS struct {
+	UDPSize       uint16
+	ExtendedRCode uint8 // upper 8 bits of the combined 12-bit RCODE, RFC 6891 6.1.3
+	Version       uint8
+	DO            bool
+	Options       []EDNSOption
+}
+
+// RCode combines this EDNS's ExtendedRCode with a message header's
+// 4-bit RCODE into the full 12-bit RCODE the two jointly encode, per
+// RFC 6891 6.1.3.
+func (e *EDNS) RCode(baseRCode uint8) uint16 {
+	return uint16(e.ExtendedRCode)<<4 | uint16(baseRCode&0x0F)
+}
+
+// SetRCode splits a combined 12-bit RCODE into e.ExtendedRCode and the
+// 4-bit value that must be written back into the message header's
+// RCODE field.
+func (e *EDNS) SetRCode(code uint16) (baseRCode uint8) {
+	e.ExtendedRCode = uint8(code >> 4)
+	return uint8(code & 0x0F)
+}
+
+// NewEDNS returns an EDNS advertising udpSize as the requestor's
+// maximum UDP payload size, with no options and no extended flags set.
+func NewEDNS(udpSize uint16) *EDNS {
+	return &EDNS{UDPSize: udpSize}
+}
+
+// EDNS returns the message's EDNS(0) pseudo-header, or nil if it has
+// none.
+func (m Message) EDNS() *EDNS {
+	return m.edns
+}
+
+// SetEDNS attaches (or replaces) the message's EDNS(0) pseudo-header.
+func (m *Message) SetEDNS(e *EDNS) {
+	m.edns = e
+}
+
+// RemoveEDNS strips any EDNS(0) pseudo-header from the message.
+func (m *Message) RemoveEDNS() {
+	m.edns = nil
+}
+
+// AddOption appends an option, replacing any existing option with the
+// same code.
+func (e *EDNS) AddOption(code uint16, data []byte) {
+	e.RemoveOption(code)
+	e.Options = append(e.Options, EDNSOption{Code: code, Data: data})
+}
+
+// RemoveOption drops the option with the given code, if present.
+func (e *EDNS) RemoveOption(code uint16) {
+	kept := e.Options[:0]
+	for _, opt := range e.Options {
+		if opt.Code != code {
+			kept = append(kept, opt)
+		}
+	}
+	e.Options = kept
+}
+
+func parseEDNSOptions(b []byte) ([]EDNSOption, error) {
+	var opts []EDNSOption
+	for pos := 0; pos < len(b); {
+		if pos+4 > len(b) {
+			return nil, ErrorInvalidHeader
+		}
+		code := binary.BigEndian.Uint16(b[pos:])
+		length := binary.BigEndian.Uint16(b[pos+2:])
+		pos += 4
+		if pos+int(length) > len(b) {
+			return nil, ErrorInvalidHeader
+		}
+		opts = append(opts, EDNSOption{Code: code, Data: append([]byte{}, b[pos:pos+int(length)]...)})
+		pos += int(length)
+	}
+	return opts, nil
+}
+
+func (e *EDNS) packOptions() []byte {
+	var buf []byte
+	for _, opt := range e.Options {
+		buf = append(buf, uint8(opt.Code>>8), uint8(opt.Code&0xff))
+		buf = append(buf, uint8(len(opt.Data)>>8), uint8(len(opt.Data)&0xff))
+		buf = append(buf, opt.Data...)
+	}
+	return buf
+}
+
+// ednsFromRR reinterprets an OPT RR's fixed fields per RFC 6891 6.1.3:
+// the RR's class holds the requestor's UDP payload size, and its TTL
+// packs the extended RCODE, version and flags (including the DO bit).
+func ednsFromRR(rr *resourceRecord, raw []byte) (*EDNS, error) {
+	opts, err := parseEDNSOptions(raw)
+	if err != nil {
+		return nil, err
+	}
+	return &EDNS{
+		UDPSize:       rr.class,
+		ExtendedRCode: uint8(rr.ttl >> 24),
+		Version:       uint8(rr.ttl >> 16),
+		DO:            uint16(rr.ttl)&ednsDOMask != 0,
+		Options:       opts,
+	}, nil
+}
+
+// rr packs e back into the OPT wire shape: root name, type 41, class =
+// UDPSize, TTL carrying extended-rcode/version/DO, RDATA = options.
+func (e *EDNS) rr() *resourceRecord {
+	ttl := uint32(e.ExtendedRCode)<<24 | uint32(e.Version)<<16
+	if e.DO {
+		ttl |= uint32(ednsDOMask)
+	}
+	return &resourceRecord{
+		name:  "",
+		rtype: uint16(Opt),
+		class: e.UDPSize,
+		ttl:   ttl,
+		rdata: &RawRData{Bytes: e.packOptions()},
+	}
+}
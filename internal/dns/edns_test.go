@@ -0,0 +1,54 @@
+package dns
+
+import "testing"
+
+// TestEDNSRoundTrip packs an EDNS pseudo-header into an OPT RR and
+// decodes it back, checking UDPSize, the DO bit, Version, options and
+// the extended RCODE all survive.
+func TestEDNSRoundTrip(t *testing.T) {
+	e := NewEDNS(4096)
+	e.Version = 0
+	e.DO = true
+	e.ExtendedRCode = 1 // combined with a 4-bit base RCODE of 0, forms BADVERS (16)
+	e.AddOption(10, []byte("cookie-data"))
+
+	rr := e.rr()
+	raw := rr.rdata.(*RawRData).Bytes
+
+	got, err := ednsFromRR(rr, raw)
+	if err != nil {
+		t.Fatalf("ednsFromRR: %v", err)
+	}
+
+	if got.UDPSize != 4096 {
+		t.Errorf("UDPSize = %d, want 4096", got.UDPSize)
+	}
+	if !got.DO {
+		t.Error("DO bit did not survive the round trip")
+	}
+	if got.Version != 0 {
+		t.Errorf("Version = %d, want 0", got.Version)
+	}
+	if got.ExtendedRCode != 1 {
+		t.Errorf("ExtendedRCode = %d, want 1", got.ExtendedRCode)
+	}
+	if got.RCode(0) != 16 {
+		t.Errorf("RCode(0) = %d, want 16 (BADVERS)", got.RCode(0))
+	}
+	if len(got.Options) != 1 || got.Options[0].Code != 10 || string(got.Options[0].Data) != "cookie-data" {
+		t.Errorf("options did not survive the round trip: %+v", got.Options)
+	}
+}
+
+// TestEDNSRCodeSplitRoundTrip checks SetRCode/RCode invert each other
+// across the 12-bit combined RCODE space.
+func TestEDNSRCodeSplitRoundTrip(t *testing.T) {
+	e := &EDNS{}
+	base := e.SetRCode(16) // BADVERS
+	if e.ExtendedRCode != 1 || base != 0 {
+		t.Errorf("SetRCode(16): ExtendedRCode=%d base=%d, want 1/0", e.ExtendedRCode, base)
+	}
+	if got := e.RCode(base); got != 16 {
+		t.Errorf("RCode(%d) = %d, want 16", base, got)
+	}
+}
@@ -0,0 +1,189 @@
+package dns
+
+import (
+	"encoding/binary"
+	"errors"
+	"sync"
+	"time"
+)
+
+const defaultForwardTimeout = 5 * time.Second
+
+var errUpstreamClosed = errors.New("dns: upstream connection closed")
+
+// ForwardHandler forwards every query to one of Upstreams, retrying
+// over TCP if the UDP reply comes back truncated, and relays the reply
+// to the original client. Queries to a given upstream share a single
+// persistent connection per transport, demultiplexed by transaction ID,
+// instead of opening one connection per query.
+type ForwardHandler struct {
+	Upstreams []string
+	Timeout   time.Duration
+
+	mu    sync.Mutex
+	conns map[string]*upstreamConn // "network|addr" -> shared conn
+}
+
+func (f *ForwardHandler) timeout() time.Duration {
+	if f.Timeout > 0 {
+		return f.Timeout
+	}
+	return defaultForwardTimeout
+}
+
+func (f *ForwardHandler) ServeDNS(w ResponseWriter, r *Message) {
+	query := r.Write()
+
+	var reply []byte
+	var err error
+	for _, addr := range f.Upstreams {
+		reply, err = f.forward(addr, "udp", query)
+		if err != nil {
+			continue
+		}
+		if NewMessage(reply).Truncated() {
+			if tcpReply, tcpErr := f.forward(addr, "tcp", query); tcpErr == nil {
+				reply = tcpReply
+			}
+		}
+		break
+	}
+	if err != nil || reply == nil {
+		return
+	}
+
+	// The upstream reply carries the transaction ID upstreamConn minted
+	// for it, not the client's; swap it back before relaying.
+	resp := NewMessage(reply)
+	resp.hdr.id = r.hdr.id
+	w.WriteMessage(resp)
+}
+
+func (f *ForwardHandler) forward(addr, network string, query []byte) ([]byte, error) {
+	uc, err := f.conn(network, addr)
+	if err != nil {
+		return nil, err
+	}
+	return uc.query(query, f.timeout())
+}
+
+func (f *ForwardHandler) conn(network, addr string) (*upstreamConn, error) {
+	key := network + "|" + addr
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.conns == nil {
+		f.conns = make(map[string]*upstreamConn)
+	}
+	if uc, ok := f.conns[key]; ok {
+		return uc, nil
+	}
+
+	uc, err := newUpstreamConn(network, addr)
+	if err != nil {
+		return nil, err
+	}
+	f.conns[key] = uc
+	return uc, nil
+}
+
+// upstreamConn is a single persistent connection to an upstream server
+// shared by concurrent queries. Each query is sent under a fresh
+// transaction ID minted by nextID rather than the original client's
+// (two clients querying concurrently could otherwise pick the same ID
+// and have their replies swapped), and replies are matched back to the
+// pending query by that ID, the same way miekg/dns's Client
+// demultiplexes a reused connection.
+type upstreamConn struct {
+	conn *Conn
+
+	mu      sync.Mutex
+	nextID  uint16
+	pending map[uint16]chan []byte
+}
+
+func newUpstreamConn(network, addr string) (*upstreamConn, error) {
+	c, err := Dial(network, addr)
+	if err != nil {
+		return nil, err
+	}
+	uc := &upstreamConn{conn: c, pending: make(map[uint16]chan []byte)}
+	go uc.readLoop()
+	return uc, nil
+}
+
+func (uc *upstreamConn) readLoop() {
+	for {
+		buf, err := uc.conn.ReadMessage()
+		if err != nil {
+			uc.abortPending()
+			return
+		}
+		if len(buf) < 2 {
+			continue
+		}
+
+		id := binary.BigEndian.Uint16(buf)
+		uc.mu.Lock()
+		ch, ok := uc.pending[id]
+		if ok {
+			delete(uc.pending, id)
+		}
+		uc.mu.Unlock()
+
+		if ok {
+			ch <- buf
+		}
+	}
+}
+
+func (uc *upstreamConn) abortPending() {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+	for id, ch := range uc.pending {
+		close(ch)
+		delete(uc.pending, id)
+	}
+}
+
+// query sends q upstream under a freshly minted transaction ID and
+// waits for the matching reply. q is not modified; a copy is sent with
+// its ID field overwritten.
+func (uc *upstreamConn) query(q []byte, timeout time.Duration) ([]byte, error) {
+	msg := append([]byte(nil), q...)
+	ch := make(chan []byte, 1)
+
+	uc.mu.Lock()
+	var id uint16
+	for {
+		id = uc.nextID
+		uc.nextID++
+		if _, busy := uc.pending[id]; !busy {
+			break
+		}
+	}
+	uc.pending[id] = ch
+	uc.mu.Unlock()
+
+	binary.BigEndian.PutUint16(msg, id)
+
+	if err := uc.conn.WriteMessage(msg); err != nil {
+		uc.mu.Lock()
+		delete(uc.pending, id)
+		uc.mu.Unlock()
+		return nil, err
+	}
+
+	select {
+	case reply, ok := <-ch:
+		if !ok {
+			return nil, errUpstreamClosed
+		}
+		return reply, nil
+	case <-time.After(timeout):
+		uc.mu.Lock()
+		delete(uc.pending, id)
+		uc.mu.Unlock()
+		return nil, errors.New("dns: upstream query timed out")
+	}
+}
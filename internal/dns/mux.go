@@ -0,0 +1,66 @@
+package dns
+
+import (
+	"strings"
+	"sync"
+)
+
+// ServeMux dispatches queries to handlers registered against qname
+// suffixes, picking the longest matching suffix, and falls back to a
+// default handler (typically a ForwardHandler) for anything unmatched.
+type ServeMux struct {
+	mu       sync.RWMutex
+	handlers map[string]Handler
+	fallback Handler
+}
+
+// NewServeMux returns an empty ServeMux.
+func NewServeMux() *ServeMux {
+	return &ServeMux{handlers: make(map[string]Handler)}
+}
+
+func normalizeSuffix(s string) string {
+	return strings.ToLower(strings.TrimSuffix(s, "."))
+}
+
+// Handle registers handler for qnames equal to or ending in suffix.
+// Matching is case-insensitive and tolerant of a trailing dot.
+func (mux *ServeMux) Handle(suffix string, handler Handler) {
+	mux.mu.Lock()
+	defer mux.mu.Unlock()
+	mux.handlers[normalizeSuffix(suffix)] = handler
+}
+
+// HandleFunc is Handle for a plain function.
+func (mux *ServeMux) HandleFunc(suffix string, handler func(ResponseWriter, *Message)) {
+	mux.Handle(suffix, HandlerFunc(handler))
+}
+
+// HandleDefault registers the handler used when no suffix matches.
+func (mux *ServeMux) HandleDefault(handler Handler) {
+	mux.mu.Lock()
+	defer mux.mu.Unlock()
+	mux.fallback = handler
+}
+
+func (mux *ServeMux) ServeDNS(w ResponseWriter, r *Message) {
+	name := normalizeSuffix(r.q.qname)
+
+	mux.mu.RLock()
+	handler := mux.fallback
+	matched := -1
+	for suffix, h := range mux.handlers {
+		if name != suffix && !strings.HasSuffix(name, "."+suffix) {
+			continue
+		}
+		if len(suffix) > matched {
+			matched = len(suffix)
+			handler = h
+		}
+	}
+	mux.mu.RUnlock()
+
+	if handler != nil {
+		handler.ServeDNS(w, r)
+	}
+}
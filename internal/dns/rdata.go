@@ -0,0 +1,294 @@
+package dns
+
+import (
+	"fmt"
+	"net"
+)
+
+// additional qtypes not covered by the original 1-16 block.
+const (
+	Aaaa qtype = 28 // a host address (IPv6)
+	Srv  qtype = 33 // service locator
+)
+
+// RData is the type-specific payload of a resource record. Pack encodes
+// it at offset (the RDATA's position in the message being built, for
+// names that may compress against earlier ones); Unpack decodes it from
+// msg starting at offset and spanning rdlen octets.
+type RData interface {
+	Pack(offset int, comp compressionMap) []byte
+	Unpack(msg []byte, offset int, rdlen int) error
+	String() string
+}
+
+// newRData returns the zero-value RData implementation for rtype, or a
+// RawRData fallback for types natedns doesn't parse yet.
+func newRData(rtype uint16) RData {
+	switch qtype(rtype) {
+	case A:
+		return &ARecord{}
+	case Aaaa:
+		return &AAAARecord{}
+	case Cname:
+		return &CNAMERecord{}
+	case Ns:
+		return &NSRecord{}
+	case Ptr:
+		return &PTRRecord{}
+	case Mx:
+		return &MXRecord{}
+	case Txt:
+		return &TXTRecord{}
+	case Soa:
+		return &SOARecord{}
+	case Srv:
+		return &SRVRecord{}
+	default:
+		return &RawRData{}
+	}
+}
+
+// ARecord is a host address, RFC 1035 3.4.1.
+type ARecord struct {
+	IP net.IP
+}
+
+func (r *ARecord) Pack(offset int, comp compressionMap) []byte {
+	return []byte(r.IP.To4())
+}
+
+func (r *ARecord) Unpack(msg []byte, offset int, rdlen int) error {
+	if rdlen != net.IPv4len {
+		return fmt.Errorf("a: rdlength %d, want %d", rdlen, net.IPv4len)
+	}
+	r.IP = net.IP(append([]byte{}, msg[offset:offset+rdlen]...))
+	return nil
+}
+
+func (r *ARecord) String() string {
+	return r.IP.String()
+}
+
+// AAAARecord is a host address, RFC 3596 2.2.
+type AAAARecord struct {
+	IP net.IP
+}
+
+func (r *AAAARecord) Pack(offset int, comp compressionMap) []byte {
+	return []byte(r.IP.To16())
+}
+
+func (r *AAAARecord) Unpack(msg []byte, offset int, rdlen int) error {
+	if rdlen != net.IPv6len {
+		return fmt.Errorf("aaaa: rdlength %d, want %d", rdlen, net.IPv6len)
+	}
+	r.IP = net.IP(append([]byte{}, msg[offset:offset+rdlen]...))
+	return nil
+}
+
+func (r *AAAARecord) String() string {
+	return r.IP.String()
+}
+
+// CNAMERecord is the canonical name for an alias, RFC 1035 3.3.1.
+type CNAMERecord struct {
+	Name string
+}
+
+func (r *CNAMERecord) Pack(offset int, comp compressionMap) []byte {
+	return writeQName(r.Name, offset, comp)
+}
+
+func (r *CNAMERecord) Unpack(msg []byte, offset int, rdlen int) error {
+	r.Name, _ = readQName(msg, offset)
+	return nil
+}
+
+func (r *CNAMERecord) String() string {
+	return r.Name
+}
+
+// NSRecord is an authoritative name server, RFC 1035 3.3.11.
+type NSRecord struct {
+	Name string
+}
+
+func (r *NSRecord) Pack(offset int, comp compressionMap) []byte {
+	return writeQName(r.Name, offset, comp)
+}
+
+func (r *NSRecord) Unpack(msg []byte, offset int, rdlen int) error {
+	r.Name, _ = readQName(msg, offset)
+	return nil
+}
+
+func (r *NSRecord) String() string {
+	return r.Name
+}
+
+// PTRRecord is a domain name pointer, RFC 1035 3.3.12.
+type PTRRecord struct {
+	Name string
+}
+
+func (r *PTRRecord) Pack(offset int, comp compressionMap) []byte {
+	return writeQName(r.Name, offset, comp)
+}
+
+func (r *PTRRecord) Unpack(msg []byte, offset int, rdlen int) error {
+	r.Name, _ = readQName(msg, offset)
+	return nil
+}
+
+func (r *PTRRecord) String() string {
+	return r.Name
+}
+
+// MXRecord is a mail exchange, RFC 1035 3.3.9.
+type MXRecord struct {
+	Pref     uint16
+	Exchange string
+}
+
+func (r *MXRecord) Pack(offset int, comp compressionMap) []byte {
+	buf := []byte{uint8(r.Pref >> 8), uint8(r.Pref & 0xff)}
+	return append(buf, writeQName(r.Exchange, offset+2, comp)...)
+}
+
+func (r *MXRecord) Unpack(msg []byte, offset int, rdlen int) error {
+	r.Pref, _ = read16(msg, offset)
+	r.Exchange, _ = readQName(msg, offset+2)
+	return nil
+}
+
+func (r *MXRecord) String() string {
+	return fmt.Sprintf("%d %s", r.Pref, r.Exchange)
+}
+
+// TXTRecord is one or more character-strings, RFC 1035 3.3.14.
+type TXTRecord struct {
+	Segments []string
+}
+
+func (r *TXTRecord) Pack(offset int, comp compressionMap) []byte {
+	var buf []byte
+	for _, s := range r.Segments {
+		buf = append(buf, byte(len(s)))
+		buf = append(buf, s...)
+	}
+	return buf
+}
+
+func (r *TXTRecord) Unpack(msg []byte, offset int, rdlen int) error {
+	end := offset + rdlen
+	r.Segments = nil
+	for pos := offset; pos < end; {
+		l := int(msg[pos])
+		pos++
+		if pos+l > end {
+			return fmt.Errorf("txt: segment runs past rdlength")
+		}
+		r.Segments = append(r.Segments, string(msg[pos:pos+l]))
+		pos += l
+	}
+	return nil
+}
+
+func (r *TXTRecord) String() string {
+	s := ""
+	for i, seg := range r.Segments {
+		if i > 0 {
+			s += " "
+		}
+		s += fmt.Sprintf("%q", seg)
+	}
+	return s
+}
+
+// SOARecord marks the start of a zone of authority, RFC 1035 3.3.13.
+type SOARecord struct {
+	MName   string
+	RName   string
+	Serial  uint32
+	Refresh uint32
+	Retry   uint32
+	Expire  uint32
+	Minimum uint32
+}
+
+func (r *SOARecord) Pack(offset int, comp compressionMap) []byte {
+	buf := writeQName(r.MName, offset, comp)
+	buf = append(buf, writeQName(r.RName, offset+len(buf), comp)...)
+	for _, v := range []uint32{r.Serial, r.Refresh, r.Retry, r.Expire, r.Minimum} {
+		buf = append(buf, uint8(v>>24), uint8(v>>16), uint8(v>>8), uint8(v))
+	}
+	return buf
+}
+
+func (r *SOARecord) Unpack(msg []byte, offset int, rdlen int) error {
+	pos := offset
+	r.MName, pos = readQName(msg, pos)
+	r.RName, pos = readQName(msg, pos)
+	r.Serial, pos = read32(msg, pos)
+	r.Refresh, pos = read32(msg, pos)
+	r.Retry, pos = read32(msg, pos)
+	r.Expire, pos = read32(msg, pos)
+	r.Minimum, _ = read32(msg, pos)
+	return nil
+}
+
+func (r *SOARecord) String() string {
+	return fmt.Sprintf(
+		"%s %s %d %d %d %d %d",
+		r.MName, r.RName, r.Serial, r.Refresh, r.Retry, r.Expire, r.Minimum,
+	)
+}
+
+// SRVRecord is a service locator, RFC 2782.
+type SRVRecord struct {
+	Priority uint16
+	Weight   uint16
+	Port     uint16
+	Target   string
+}
+
+func (r *SRVRecord) Pack(offset int, comp compressionMap) []byte {
+	buf := []byte{
+		uint8(r.Priority >> 8), uint8(r.Priority & 0xff),
+		uint8(r.Weight >> 8), uint8(r.Weight & 0xff),
+		uint8(r.Port >> 8), uint8(r.Port & 0xff),
+	}
+	return append(buf, writeQName(r.Target, offset+6, comp)...)
+}
+
+func (r *SRVRecord) Unpack(msg []byte, offset int, rdlen int) error {
+	pos := offset
+	r.Priority, pos = read16(msg, pos)
+	r.Weight, pos = read16(msg, pos)
+	r.Port, pos = read16(msg, pos)
+	r.Target, _ = readQName(msg, pos)
+	return nil
+}
+
+func (r *SRVRecord) String() string {
+	return fmt.Sprintf("%d %d %d %s", r.Priority, r.Weight, r.Port, r.Target)
+}
+
+// RawRData is the fallback for rtypes natedns doesn't parse; it passes
+// the wire bytes through unchanged.
+type RawRData struct {
+	Bytes []byte
+}
+
+func (r *RawRData) Pack(offset int, comp compressionMap) []byte {
+	return r.Bytes
+}
+
+func (r *RawRData) Unpack(msg []byte, offset int, rdlen int) error {
+	r.Bytes = append([]byte{}, msg[offset:offset+rdlen]...)
+	return nil
+}
+
+func (r *RawRData) String() string {
+	return fmt.Sprintf("% x", r.Bytes)
+}
@@ -0,0 +1,97 @@
+package dns
+
+import (
+	"net"
+	"testing"
+)
+
+// packUnpack packs rd at offset 0 into a standalone buffer and unpacks
+// a fresh zero-value of the same type from it, returning the result for
+// the caller to inspect.
+func packUnpack(t *testing.T, rtype uint16, rd RData) RData {
+	t.Helper()
+	buf := rd.Pack(0, nil)
+
+	out := newRData(rtype)
+	if err := out.Unpack(buf, 0, len(buf)); err != nil {
+		t.Fatalf("Unpack: %v", err)
+	}
+	return out
+}
+
+func TestARecordRoundTrip(t *testing.T) {
+	in := &ARecord{IP: mustParseIP("192.0.2.1").To4()}
+	out := packUnpack(t, uint16(A), in).(*ARecord)
+	if out.String() != in.String() {
+		t.Errorf("got %s, want %s", out, in)
+	}
+}
+
+func TestAAAARecordRoundTrip(t *testing.T) {
+	in := &AAAARecord{IP: mustParseIP("2001:db8::1")}
+	out := packUnpack(t, uint16(Aaaa), in).(*AAAARecord)
+	if out.String() != in.String() {
+		t.Errorf("got %s, want %s", out, in)
+	}
+}
+
+func TestCNAMERecordRoundTrip(t *testing.T) {
+	in := &CNAMERecord{Name: "target.example.com"}
+	out := packUnpack(t, uint16(Cname), in).(*CNAMERecord)
+	if out.Name != in.Name {
+		t.Errorf("got %q, want %q", out.Name, in.Name)
+	}
+}
+
+func TestMXRecordRoundTrip(t *testing.T) {
+	in := &MXRecord{Pref: 10, Exchange: "mail.example.com"}
+	out := packUnpack(t, uint16(Mx), in).(*MXRecord)
+	if out.Pref != in.Pref || out.Exchange != in.Exchange {
+		t.Errorf("got %+v, want %+v", out, in)
+	}
+}
+
+func TestTXTRecordRoundTrip(t *testing.T) {
+	in := &TXTRecord{Segments: []string{"hello", "", "world"}}
+	out := packUnpack(t, uint16(Txt), in).(*TXTRecord)
+	if len(out.Segments) != len(in.Segments) {
+		t.Fatalf("got %d segments, want %d", len(out.Segments), len(in.Segments))
+	}
+	for i := range in.Segments {
+		if out.Segments[i] != in.Segments[i] {
+			t.Errorf("segment %d: got %q, want %q", i, out.Segments[i], in.Segments[i])
+		}
+	}
+}
+
+func TestSOARecordRoundTrip(t *testing.T) {
+	in := &SOARecord{
+		MName:   "ns1.example.com",
+		RName:   "hostmaster.example.com",
+		Serial:  2024010100,
+		Refresh: 7200,
+		Retry:   3600,
+		Expire:  1209600,
+		Minimum: 300,
+	}
+	out := packUnpack(t, uint16(Soa), in).(*SOARecord)
+	if *out != *in {
+		t.Errorf("got %+v, want %+v", out, in)
+	}
+}
+
+func TestSRVRecordRoundTrip(t *testing.T) {
+	in := &SRVRecord{Priority: 1, Weight: 2, Port: 5060, Target: "sip.example.com"}
+	out := packUnpack(t, uint16(Srv), in).(*SRVRecord)
+	if *out != *in {
+		t.Errorf("got %+v, want %+v", out, in)
+	}
+}
+
+func mustParseIP(s string) net.IP {
+	ip := net.ParseIP(s)
+	if ip == nil {
+		panic("invalid test IP " + s)
+	}
+	return ip
+}
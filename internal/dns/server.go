@@ -0,0 +1,164 @@
+package dns
+
+import (
+	"fmt"
+	"net"
+)
+
+// defaultMaxConcurrency bounds how many queries a Server will handle at
+// once when MaxConcurrency is left at zero, so a burst of traffic can't
+// spawn an unbounded number of goroutines.
+const defaultMaxConcurrency = 256
+
+// ResponseWriter lets a Handler reply to whichever client sent a query,
+// regardless of whether it arrived over UDP or TCP.
+type ResponseWriter interface {
+	WriteMessage(m *Message) error
+	RemoteAddr() net.Addr
+}
+
+// Handler answers a single query delivered to ServeDNS.
+type Handler interface {
+	ServeDNS(w ResponseWriter, r *Message)
+}
+
+// HandlerFunc adapts a plain function to a Handler.
+type HandlerFunc func(w ResponseWriter, r *Message)
+
+func (f HandlerFunc) ServeDNS(w ResponseWriter, r *Message) {
+	f(w, r)
+}
+
+// Server listens on a single address/transport and dispatches each
+// inbound UDP query, or each accepted TCP connection, to Handler from
+// its own goroutine, bounded by MaxConcurrency. Queries on one TCP
+// connection are handled one after another.
+type Server struct {
+	Handler        Handler
+	MaxConcurrency int // 0 means defaultMaxConcurrency
+}
+
+func (s *Server) maxConcurrency() int {
+	if s.MaxConcurrency > 0 {
+		return s.MaxConcurrency
+	}
+	return defaultMaxConcurrency
+}
+
+// ListenAndServe listens on addr over network ("udp" or "tcp") and
+// serves queries until a fatal listener error occurs.
+func (s *Server) ListenAndServe(addr, network string) error {
+	switch network {
+	case "udp":
+		return s.serveUDP(addr)
+	case "tcp":
+		return s.serveTCP(addr)
+	default:
+		return fmt.Errorf("dns: unsupported network %q", network)
+	}
+}
+
+func (s *Server) serveUDP(addr string) error {
+	conn, err := ListenUDP(addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	sem := make(chan struct{}, s.maxConcurrency())
+	for {
+		buf := make([]byte, MaxUDPSize)
+		n, raddr, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			return err
+		}
+		buf = buf[:n]
+
+		sem <- struct{}{}
+		go func() {
+			defer func() { <-sem }()
+			s.dispatch(buf, &udpResponseWriter{conn: conn, addr: raddr})
+		}()
+	}
+}
+
+func (s *Server) serveTCP(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer ln.Close()
+
+	sem := make(chan struct{}, s.maxConcurrency())
+	for {
+		nc, err := ln.Accept()
+		if err != nil {
+			return err
+		}
+
+		sem <- struct{}{}
+		go func() {
+			defer func() { <-sem }()
+			defer nc.Close()
+			s.serveTCPConn(nc)
+		}()
+	}
+}
+
+// serveTCPConn handles one accepted TCP connection, which may carry
+// several pipelined queries before the client closes it.
+func (s *Server) serveTCPConn(nc net.Conn) {
+	c := &Conn{network: "tcp", nc: nc}
+	w := &tcpResponseWriter{conn: c, addr: nc.RemoteAddr()}
+	for {
+		buf, err := c.ReadMessage()
+		if err != nil {
+			return
+		}
+		s.dispatch(buf, w)
+	}
+}
+
+// dispatch decodes buf and runs it through Handler. NewMessage (and the
+// Handler itself) can panic on malformed input; one bad packet must not
+// take the whole server down, so it's recovered here, logged, and
+// dropped.
+func (s *Server) dispatch(buf []byte, w ResponseWriter) {
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Println("dns: dropping malformed query:", r)
+		}
+	}()
+
+	if s.Handler == nil {
+		return
+	}
+	s.Handler.ServeDNS(w, NewMessage(buf))
+}
+
+type udpResponseWriter struct {
+	conn *net.UDPConn
+	addr *net.UDPAddr
+}
+
+func (w *udpResponseWriter) WriteMessage(m *Message) error {
+	_, err := w.conn.WriteToUDP(m.Write(), w.addr)
+	return err
+}
+
+func (w *udpResponseWriter) RemoteAddr() net.Addr {
+	return w.addr
+}
+
+type tcpResponseWriter struct {
+	conn *Conn
+	addr net.Addr
+}
+
+func (w *tcpResponseWriter) WriteMessage(m *Message) error {
+	return w.conn.WriteMessage(m.Write())
+}
+
+func (w *tcpResponseWriter) RemoteAddr() net.Addr {
+	return w.addr
+}
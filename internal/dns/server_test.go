@@ -0,0 +1,49 @@
+package dns
+
+import (
+	"net"
+	"testing"
+)
+
+type nopResponseWriter struct{}
+
+func (nopResponseWriter) WriteMessage(m *Message) error { return nil }
+func (nopResponseWriter) RemoteAddr() net.Addr          { return nil }
+
+// TestDispatchRecoversFromPanic checks that a malformed packet panicking
+// out of NewMessage is recovered in dispatch, instead of taking the
+// whole process down, and that the server keeps working afterwards.
+func TestDispatchRecoversFromPanic(t *testing.T) {
+	var called int
+	s := &Server{Handler: HandlerFunc(func(w ResponseWriter, r *Message) {
+		called++
+	})}
+
+	// A 12-byte header claiming 1 question but no question bytes at all
+	// panics in readQName.
+	malformed := make([]byte, 12)
+	malformed[4] = 0
+	malformed[5] = 1 // questions = 1
+
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				t.Fatalf("dispatch let a panic escape: %v", r)
+			}
+		}()
+		s.dispatch(malformed, nopResponseWriter{})
+	}()
+
+	if called != 0 {
+		t.Errorf("Handler was called %d times on a malformed packet, want 0", called)
+	}
+
+	good := (&Message{
+		hdr: header{id: 1, questions: 1, rd: 1},
+		q:   question{qname: "example.com", qtype: uint16(A), qclass: uint16(In)},
+	}).Write()
+	s.dispatch(good, nopResponseWriter{})
+	if called != 1 {
+		t.Errorf("Handler was called %d times on a well-formed packet after recovery, want 1", called)
+	}
+}
@@ -0,0 +1,474 @@
+package dns
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ResourceRecord is the public name for resourceRecord, used wherever a
+// Zone hands one back to a caller outside the file it was decoded/built
+// in.
+type ResourceRecord = resourceRecord
+
+var zoneTypeNames = map[string]uint16{
+	"A":     uint16(A),
+	"NS":    uint16(Ns),
+	"CNAME": uint16(Cname),
+	"SOA":   uint16(Soa),
+	"PTR":   uint16(Ptr),
+	"MX":    uint16(Mx),
+	"TXT":   uint16(Txt),
+	"AAAA":  uint16(Aaaa),
+	"SRV":   uint16(Srv),
+}
+
+var zoneClassNames = map[string]bool{
+	"IN": true, "CS": true, "CH": true, "HS": true,
+}
+
+// zoneRecord is one logical master-file record: a run of tokens spread
+// across one physical line, or several joined by parentheses.
+type zoneRecord struct {
+	ownerOmitted bool // leading whitespace on the first physical line: reuse the previous owner
+	tokens       []string
+}
+
+// zoneNode holds the RRsets directly owned by one name in the zone.
+type zoneNode struct {
+	sets map[uint16][]*resourceRecord
+}
+
+// Zone is an in-memory authoritative zone parsed from RFC 1035
+// master-file syntax, keyed by lowercased, dot-free owner name (the
+// same convention dns.Message names use).
+type Zone struct {
+	Origin string
+	nodes  map[string]*zoneNode
+	soa    *resourceRecord
+}
+
+// ParseZone parses a master file from r. origin seeds the initial
+// $ORIGIN (e.g. "example.com."), and defaultTTL seeds the initial $TTL;
+// either may be overridden by directives in the file itself.
+func ParseZone(r io.Reader, origin string, defaultTTL uint32) (*Zone, error) {
+	records, err := tokenizeZone(r)
+	if err != nil {
+		return nil, err
+	}
+
+	z := &Zone{Origin: normalizeName(origin), nodes: make(map[string]*zoneNode)}
+	lastOwner := z.Origin
+	ttl := defaultTTL
+
+	for _, rec := range records {
+		if len(rec.tokens) == 0 {
+			continue
+		}
+
+		switch strings.ToUpper(rec.tokens[0]) {
+		case "$ORIGIN":
+			if len(rec.tokens) < 2 {
+				return nil, fmt.Errorf("zone: $ORIGIN missing a value")
+			}
+			z.Origin = normalizeName(expandName(rec.tokens[1], z.Origin))
+			continue
+		case "$TTL":
+			if len(rec.tokens) < 2 {
+				return nil, fmt.Errorf("zone: $TTL missing a value")
+			}
+			n, err := strconv.ParseUint(rec.tokens[1], 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("zone: invalid $TTL %q: %w", rec.tokens[1], err)
+			}
+			ttl = uint32(n)
+			continue
+		}
+
+		name, recTTL, rtype, rdata, err := parseRecordLine(rec, z.Origin, lastOwner, ttl)
+		if err != nil {
+			return nil, err
+		}
+		lastOwner = name
+
+		z.add(&resourceRecord{name: name, rtype: rtype, class: uint16(In), ttl: recTTL, rdata: rdata})
+	}
+
+	return z, nil
+}
+
+func (z *Zone) add(rr *resourceRecord) {
+	node, ok := z.nodes[rr.name]
+	if !ok {
+		node = &zoneNode{sets: make(map[uint16][]*resourceRecord)}
+		z.nodes[rr.name] = node
+	}
+	node.sets[rr.rtype] = append(node.sets[rr.rtype], rr)
+	if qtype(rr.rtype) == Soa && rr.name == z.Origin {
+		z.soa = rr
+	}
+}
+
+func (z *Zone) contains(name string) bool {
+	return name == z.Origin || strings.HasSuffix(name, "."+z.Origin)
+}
+
+// hasDescendant reports whether some owner name in the zone lies below
+// name, i.e. name is an empty non-terminal: it owns no RRs itself but
+// exists implicitly because something under it does (RFC 2308 2.2).
+func (z *Zone) hasDescendant(name string) bool {
+	suffix := "." + name
+	for owner := range z.nodes {
+		if owner != name && strings.HasSuffix(owner, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Authority returns the zone apex's SOA record, for the authority
+// section of NXDOMAIN/NODATA responses.
+func (z *Zone) Authority() (ResourceRecord, bool) {
+	if z.soa == nil {
+		return ResourceRecord{}, false
+	}
+	return *z.soa, true
+}
+
+// Lookup answers qname/qtype against the zone. authoritative is true
+// whenever qname falls within the zone at all; nxdomain distinguishes
+// "the owner name itself doesn't exist" from NODATA (owner exists, just
+// not for this qtype, or is an empty non-terminal with records only
+// below it, RFC 2308 2.2).
+func (z *Zone) Lookup(qname string, qtypeVal uint16) (rrs []ResourceRecord, authoritative bool, nxdomain bool) {
+	name := normalizeName(qname)
+	if !z.contains(name) {
+		return nil, false, false
+	}
+
+	node, ok := z.nodes[name]
+	if !ok {
+		if z.hasDescendant(name) {
+			return nil, true, false
+		}
+		return nil, true, true
+	}
+
+	set, ok := node.sets[qtypeVal]
+	if !ok {
+		return nil, true, false
+	}
+
+	out := make([]ResourceRecord, len(set))
+	for i, rr := range set {
+		out[i] = *rr
+	}
+	return out, true, false
+}
+
+// ZoneHandler answers queries authoritatively from Zone, setting the
+// AA bit, and falls through to Next for anything outside it.
+type ZoneHandler struct {
+	Zone *Zone
+	Next Handler
+}
+
+func (h *ZoneHandler) ServeDNS(w ResponseWriter, r *Message) {
+	rrs, authoritative, nxdomain := h.Zone.Lookup(r.q.qname, r.q.qtype)
+	if !authoritative {
+		if h.Next != nil {
+			h.Next.ServeDNS(w, r)
+		}
+		return
+	}
+
+	resp := &Message{hdr: r.hdr, q: r.q}
+	resp.hdr.qr = 1
+	resp.hdr.aa = 1
+	if nxdomain {
+		resp.hdr.rcode = uint8(ErrorName)
+	}
+
+	if len(rrs) > 0 {
+		for i := range rrs {
+			answer := rrs[i]
+			resp.answers = append(resp.answers, &answer)
+		}
+	} else if soa, ok := h.Zone.Authority(); ok {
+		resp.authorities = append(resp.authorities, &soa)
+	}
+
+	w.WriteMessage(resp)
+}
+
+func expandName(raw, origin string) string {
+	switch {
+	case raw == "@" || raw == "":
+		return origin
+	case strings.HasSuffix(raw, "."):
+		return strings.TrimSuffix(raw, ".")
+	default:
+		return raw + "." + origin
+	}
+}
+
+func normalizeName(s string) string {
+	return strings.ToLower(strings.TrimSuffix(s, "."))
+}
+
+func parseRecordLine(rec zoneRecord, origin, lastOwner string, defaultTTL uint32) (string, uint32, uint16, RData, error) {
+	tokens := rec.tokens
+	idx := 0
+
+	var name string
+	if rec.ownerOmitted {
+		name = lastOwner
+	} else {
+		name = normalizeName(expandName(tokens[0], origin))
+		idx = 1
+	}
+
+	ttl := defaultTTL
+	var rtype uint16
+	haveType := false
+	for idx < len(tokens) {
+		tok := tokens[idx]
+		upper := strings.ToUpper(tok)
+		if t, ok := zoneTypeNames[upper]; ok {
+			rtype = t
+			idx++
+			haveType = true
+			break
+		}
+		if zoneClassNames[upper] {
+			idx++
+			continue
+		}
+		if n, err := strconv.ParseUint(tok, 10, 32); err == nil {
+			ttl = uint32(n)
+			idx++
+			continue
+		}
+		return "", 0, 0, nil, fmt.Errorf("zone: unexpected token %q in record for %q", tok, name)
+	}
+	if !haveType {
+		return "", 0, 0, nil, fmt.Errorf("zone: record for %q has no type", name)
+	}
+
+	rdata, err := parseRData(rtype, tokens[idx:], origin)
+	if err != nil {
+		return "", 0, 0, nil, err
+	}
+	return name, ttl, rtype, rdata, nil
+}
+
+func parseRData(rtype uint16, fields []string, origin string) (RData, error) {
+	switch qtype(rtype) {
+	case A:
+		if len(fields) != 1 {
+			return nil, fmt.Errorf("zone: A record wants 1 field, got %d", len(fields))
+		}
+		ip := net.ParseIP(fields[0])
+		if ip == nil || ip.To4() == nil {
+			return nil, fmt.Errorf("zone: invalid A address %q", fields[0])
+		}
+		return &ARecord{IP: ip.To4()}, nil
+
+	case Aaaa:
+		if len(fields) != 1 {
+			return nil, fmt.Errorf("zone: AAAA record wants 1 field, got %d", len(fields))
+		}
+		ip := net.ParseIP(fields[0])
+		if ip == nil {
+			return nil, fmt.Errorf("zone: invalid AAAA address %q", fields[0])
+		}
+		return &AAAARecord{IP: ip.To16()}, nil
+
+	case Ns:
+		if len(fields) != 1 {
+			return nil, fmt.Errorf("zone: NS record wants 1 field, got %d", len(fields))
+		}
+		return &NSRecord{Name: normalizeName(expandName(fields[0], origin))}, nil
+
+	case Cname:
+		if len(fields) != 1 {
+			return nil, fmt.Errorf("zone: CNAME record wants 1 field, got %d", len(fields))
+		}
+		return &CNAMERecord{Name: normalizeName(expandName(fields[0], origin))}, nil
+
+	case Ptr:
+		if len(fields) != 1 {
+			return nil, fmt.Errorf("zone: PTR record wants 1 field, got %d", len(fields))
+		}
+		return &PTRRecord{Name: normalizeName(expandName(fields[0], origin))}, nil
+
+	case Mx:
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("zone: MX record wants 2 fields, got %d", len(fields))
+		}
+		pref, err := strconv.ParseUint(fields[0], 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("zone: invalid MX preference %q", fields[0])
+		}
+		return &MXRecord{Pref: uint16(pref), Exchange: normalizeName(expandName(fields[1], origin))}, nil
+
+	case Txt:
+		return &TXTRecord{Segments: append([]string{}, fields...)}, nil
+
+	case Soa:
+		if len(fields) != 7 {
+			return nil, fmt.Errorf("zone: SOA record wants 7 fields, got %d", len(fields))
+		}
+		nums := make([]uint32, 5)
+		for i, f := range fields[2:] {
+			n, err := strconv.ParseUint(f, 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("zone: invalid SOA field %q", f)
+			}
+			nums[i] = uint32(n)
+		}
+		return &SOARecord{
+			MName:   normalizeName(expandName(fields[0], origin)),
+			RName:   normalizeName(expandName(fields[1], origin)),
+			Serial:  nums[0],
+			Refresh: nums[1],
+			Retry:   nums[2],
+			Expire:  nums[3],
+			Minimum: nums[4],
+		}, nil
+
+	case Srv:
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("zone: SRV record wants 4 fields, got %d", len(fields))
+		}
+		priority, err := strconv.ParseUint(fields[0], 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("zone: invalid SRV priority %q", fields[0])
+		}
+		weight, err := strconv.ParseUint(fields[1], 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("zone: invalid SRV weight %q", fields[1])
+		}
+		port, err := strconv.ParseUint(fields[2], 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("zone: invalid SRV port %q", fields[2])
+		}
+		return &SRVRecord{
+			Priority: uint16(priority),
+			Weight:   uint16(weight),
+			Port:     uint16(port),
+			Target:   normalizeName(expandName(fields[3], origin)),
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("zone: unsupported record type %d", rtype)
+	}
+}
+
+// tokenizeZone splits a master file into logical records, joining
+// parenthesized groups and stripping comments and quoting.
+func tokenizeZone(r io.Reader) ([]zoneRecord, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+
+	var records []zoneRecord
+	var cur zoneRecord
+	depth := 0
+	started := false
+
+	for scanner.Scan() {
+		raw := scanner.Text()
+		if !started {
+			cur = zoneRecord{ownerOmitted: len(raw) > 0 && unicode.IsSpace(rune(raw[0]))}
+			started = true
+		}
+
+		toks, newDepth, err := tokenizeZoneLine(raw, depth)
+		if err != nil {
+			return nil, err
+		}
+		cur.tokens = append(cur.tokens, toks...)
+		depth = newDepth
+
+		if depth == 0 {
+			if len(cur.tokens) > 0 {
+				records = append(records, cur)
+			}
+			started = false
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if depth != 0 {
+		return nil, fmt.Errorf("zone: unbalanced parentheses")
+	}
+	return records, nil
+}
+
+// tokenizeZoneLine tokenizes one physical line, given the paren depth
+// carried in from prior lines, and returns the depth carried out.
+func tokenizeZoneLine(line string, depth int) ([]string, int, error) {
+	var tokens []string
+	var tok strings.Builder
+	inQuotes := false
+
+	flush := func() {
+		if tok.Len() > 0 {
+			tokens = append(tokens, tok.String())
+			tok.Reset()
+		}
+	}
+
+	runes := []rune(line)
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+
+		if inQuotes {
+			switch {
+			case c == '\\' && i+1 < len(runes):
+				i++
+				tok.WriteRune(runes[i])
+			case c == '"':
+				inQuotes = false
+				tokens = append(tokens, tok.String())
+				tok.Reset()
+			default:
+				tok.WriteRune(c)
+			}
+			continue
+		}
+
+		switch {
+		case c == ';':
+			flush()
+			return tokens, depth, nil
+		case c == '"':
+			flush()
+			inQuotes = true
+		case c == '(':
+			flush()
+			depth++
+		case c == ')':
+			flush()
+			depth--
+			if depth < 0 {
+				return nil, depth, fmt.Errorf("zone: unbalanced parentheses")
+			}
+		case unicode.IsSpace(c):
+			flush()
+		default:
+			tok.WriteRune(c)
+		}
+	}
+
+	if inQuotes {
+		return nil, depth, fmt.Errorf("zone: unterminated quoted string")
+	}
+	flush()
+	return tokens, depth, nil
+}
@@ -0,0 +1,97 @@
+package dns
+
+import (
+	"net"
+	"strings"
+	"testing"
+)
+
+const testZoneFile = `
+$ORIGIN example.com.
+$TTL 3600
+@       SOA ns1.example.com. hostmaster.example.com. 1 7200 3600 1209600 300
+@       NS  ns1.example.com.
+@       NS  ns2.example.com.
+@       A   192.0.2.1
+www     A   192.0.2.10
+a.b     A   192.0.2.20
+`
+
+func mustParseTestZone(t *testing.T) *Zone {
+	t.Helper()
+	z, err := ParseZone(strings.NewReader(testZoneFile), "example.com.", 3600)
+	if err != nil {
+		t.Fatalf("ParseZone: %v", err)
+	}
+	return z
+}
+
+// TestZoneLookupReturnsFullRRset checks that a name with more than one
+// record of a type (the apex's two NS records) comes back in full,
+// rather than only the first.
+func TestZoneLookupReturnsFullRRset(t *testing.T) {
+	z := mustParseTestZone(t)
+
+	rrs, authoritative, nxdomain := z.Lookup("example.com", uint16(Ns))
+	if !authoritative || nxdomain {
+		t.Fatalf("authoritative=%v nxdomain=%v, want true/false", authoritative, nxdomain)
+	}
+	if len(rrs) != 2 {
+		t.Fatalf("got %d NS records, want 2", len(rrs))
+	}
+}
+
+// TestZoneHandlerReturnsFullRRset checks the same through ZoneHandler,
+// which used to keep only rrs[0].
+func TestZoneHandlerReturnsFullRRset(t *testing.T) {
+	z := mustParseTestZone(t)
+	h := &ZoneHandler{Zone: z}
+
+	req := &Message{
+		hdr: header{id: 1, questions: 1, rd: 1},
+		q:   question{qname: "example.com", qtype: uint16(Ns), qclass: uint16(In)},
+	}
+	rw := &recordingWriter{}
+	h.ServeDNS(rw, req)
+
+	if rw.msg == nil {
+		t.Fatal("ServeDNS did not write a response")
+	}
+	if len(rw.msg.answers) != 2 {
+		t.Fatalf("got %d answers, want 2", len(rw.msg.answers))
+	}
+}
+
+// TestZoneLookupNodataVsNxdomain covers the three cases Lookup must
+// distinguish: an exact match, a true NXDOMAIN, and an empty
+// non-terminal (NODATA), per RFC 2308 2.2.
+func TestZoneLookupNodataVsNxdomain(t *testing.T) {
+	z := mustParseTestZone(t)
+
+	if _, authoritative, nxdomain := z.Lookup("www.example.com", uint16(A)); !authoritative || nxdomain {
+		t.Errorf("www.example.com/A: authoritative=%v nxdomain=%v, want true/false", authoritative, nxdomain)
+	}
+
+	if _, authoritative, nxdomain := z.Lookup("nope.example.com", uint16(A)); !authoritative || !nxdomain {
+		t.Errorf("nope.example.com/A: authoritative=%v nxdomain=%v, want true/true", authoritative, nxdomain)
+	}
+
+	// b.example.com owns no records of its own, but a.b.example.com
+	// does, so it's an empty non-terminal: NODATA, not NXDOMAIN.
+	if _, authoritative, nxdomain := z.Lookup("b.example.com", uint16(A)); !authoritative || nxdomain {
+		t.Errorf("b.example.com/A: authoritative=%v nxdomain=%v, want true/false (NODATA)", authoritative, nxdomain)
+	}
+}
+
+type recordingWriter struct {
+	msg *Message
+}
+
+func (w *recordingWriter) WriteMessage(m *Message) error {
+	w.msg = m
+	return nil
+}
+
+func (w *recordingWriter) RemoteAddr() net.Addr {
+	return nil
+}